@@ -0,0 +1,215 @@
+package tokenization
+
+import "errors"
+
+// errJSONTruncated signals that a consume function ran off the end of the
+// buffered input before finding a value's closing byte. The caller should
+// refill and retry if more input may still be coming; at true EOF it means
+// the value never closed.
+var errJSONTruncated = errors.New("tokenization: truncated JSON value")
+
+// errJSONMalformed signals that the bytes at a value's opening position
+// aren't valid JSON. The Scanner then tokenizes the value with the
+// byte-level tokenizer from its first byte, rather than discarding the
+// line; no part of it is walked as JSON.
+var errJSONMalformed = errors.New("tokenization: malformed JSON value")
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func skipJSONSpace(buf []byte, i int) int {
+	for i < len(buf) && isJSONSpace(buf[i]) {
+		i++
+	}
+	return i
+}
+
+// consumeString returns the offset just past the closing quote of the JSON
+// string starting at buf[i] (which must itself be a '"'). It doesn't
+// unescape or allocate; escaped bytes are simply skipped over.
+func consumeString(buf []byte, i int) (int, error) {
+	if i >= len(buf) || buf[i] != '"' {
+		return i, errJSONMalformed
+	}
+	for i++; i < len(buf); i++ {
+		switch buf[i] {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			i++
+		}
+	}
+	return i, errJSONTruncated
+}
+
+// consumeNumber returns the offset just past the JSON number starting at
+// buf[i].
+func consumeNumber(buf []byte, i int) (int, error) {
+	start := i
+	if i < len(buf) && buf[i] == '-' {
+		i++
+	}
+	for i < len(buf) && buf[i] >= '0' && buf[i] <= '9' {
+		i++
+	}
+	if i < len(buf) && buf[i] == '.' {
+		i++
+		for i < len(buf) && buf[i] >= '0' && buf[i] <= '9' {
+			i++
+		}
+	}
+	if i < len(buf) && (buf[i] == 'e' || buf[i] == 'E') {
+		i++
+		if i < len(buf) && (buf[i] == '+' || buf[i] == '-') {
+			i++
+		}
+		for i < len(buf) && buf[i] >= '0' && buf[i] <= '9' {
+			i++
+		}
+	}
+	if i == start {
+		return i, errJSONMalformed
+	}
+	if i >= len(buf) {
+		// A number has no closing byte of its own, so running off the end of
+		// the buffer doesn't show where it ends: more digits may follow.
+		return i, errJSONTruncated
+	}
+	return i, nil
+}
+
+func consumeLiteral(buf []byte, i int, lit string) (int, error) {
+	if i+len(lit) > len(buf) {
+		return i, errJSONTruncated
+	}
+	for j := 0; j < len(lit); j++ {
+		if buf[i+j] != lit[j] {
+			return i, errJSONMalformed
+		}
+	}
+	return i + len(lit), nil
+}
+
+// consumeBareValue tolerates a Preprocess placeholder (e.g. "<NUM>") or any
+// other non-JSON scalar sitting where a value is expected, by consuming up
+// to the next structural byte. Preprocess runs over the whole line before
+// the Scanner ever sees it, so a numeric field can easily no longer look
+// like a JSON number by the time we get here.
+func consumeBareValue(buf []byte, i int) (int, error) {
+	start := i
+	for i < len(buf) {
+		c := buf[i]
+		if c == ',' || c == '}' || c == ']' || isJSONSpace(c) {
+			break
+		}
+		i++
+	}
+	if i == start {
+		return i, errJSONMalformed
+	}
+	if i >= len(buf) {
+		return i, errJSONTruncated
+	}
+	return i, nil
+}
+
+// consumeValue returns the offset just past the JSON value starting at
+// buf[i], recursing into consumeObject/consumeArray for containers.
+func consumeValue(buf []byte, i int) (int, error) {
+	if i >= len(buf) {
+		return i, errJSONTruncated
+	}
+	switch c := buf[i]; {
+	case c == '"':
+		return consumeString(buf, i)
+	case c == '{':
+		return consumeObject(buf, i)
+	case c == '[':
+		return consumeArray(buf, i)
+	case c == 't':
+		return consumeLiteral(buf, i, "true")
+	case c == 'f':
+		return consumeLiteral(buf, i, "false")
+	case c == 'n':
+		return consumeLiteral(buf, i, "null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		return consumeNumber(buf, i)
+	default:
+		return consumeBareValue(buf, i)
+	}
+}
+
+// consumeObject returns the offset just past the closing '}' of the JSON
+// object starting at buf[i].
+func consumeObject(buf []byte, i int) (int, error) {
+	if i >= len(buf) || buf[i] != '{' {
+		return i, errJSONMalformed
+	}
+	i = skipJSONSpace(buf, i+1)
+	if i < len(buf) && buf[i] == '}' {
+		return i + 1, nil
+	}
+	for {
+		var err error
+		i, err = consumeString(buf, i)
+		if err != nil {
+			return i, err
+		}
+		i = skipJSONSpace(buf, i)
+		if i >= len(buf) {
+			return i, errJSONTruncated
+		}
+		if buf[i] != ':' {
+			return i, errJSONMalformed
+		}
+		i = skipJSONSpace(buf, i+1)
+		i, err = consumeValue(buf, i)
+		if err != nil {
+			return i, err
+		}
+		i = skipJSONSpace(buf, i)
+		if i >= len(buf) {
+			return i, errJSONTruncated
+		}
+		switch buf[i] {
+		case ',':
+			i = skipJSONSpace(buf, i+1)
+		case '}':
+			return i + 1, nil
+		default:
+			return i, errJSONMalformed
+		}
+	}
+}
+
+// consumeArray returns the offset just past the closing ']' of the JSON
+// array starting at buf[i].
+func consumeArray(buf []byte, i int) (int, error) {
+	if i >= len(buf) || buf[i] != '[' {
+		return i, errJSONMalformed
+	}
+	i = skipJSONSpace(buf, i+1)
+	if i < len(buf) && buf[i] == ']' {
+		return i + 1, nil
+	}
+	for {
+		var err error
+		i, err = consumeValue(buf, i)
+		if err != nil {
+			return i, err
+		}
+		i = skipJSONSpace(buf, i)
+		if i >= len(buf) {
+			return i, errJSONTruncated
+		}
+		switch buf[i] {
+		case ',':
+			i = skipJSONSpace(buf, i+1)
+		case ']':
+			return i + 1, nil
+		default:
+			return i, errJSONMalformed
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tokenization
+
+// Replacer is a single Preprocess substitution: Match looks at buf starting
+// at i and, if it recognizes something there, returns the offset just past
+// it; Preprocess then writes Placeholder in its place. Matchers are plain
+// hand-written scanners, not regexps, so they stay allocation-free and fast
+// at the token-boundary check Preprocess does before calling them.
+type Replacer struct {
+	Name        string
+	Match       func(buf []byte, i int) (end int, ok bool)
+	Placeholder []byte
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isAlphaNum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// preprocessBoundary extends delimiters[] with punctuation that commonly
+// sits right before a value worth collapsing but isn't itself a tokenizer
+// delimiter: key=value, key:"value", JSON's quoting and field separators.
+// Without these, a Replacer would only fire on a value that starts the line
+// or follows whitespace, never on one inside key=value, a quoted string or
+// a comma-separated list.
+var preprocessBoundary = func() [256]bool {
+	b := delimiters
+	for _, c := range []byte{'=', ':', '"', '\'', '`', ',', '(', '[', '{'} {
+		b[c] = true
+	}
+	return b
+}()
+
+// matchNumber recognizes a run of digits, with an optional leading '-' and a
+// decimal point, e.g. "123", "-42", "3.14".
+func matchNumber(buf []byte, i int) (int, bool) {
+	j := i
+	if buf[j] == '-' && j+1 < len(buf) && isDigit(buf[j+1]) {
+		j++
+	}
+	start := j
+	for j < len(buf) && isDigit(buf[j]) {
+		j++
+	}
+	if j == start {
+		return i, false
+	}
+	if j < len(buf) && buf[j] == '.' && j+1 < len(buf) && isDigit(buf[j+1]) {
+		j++
+		for j < len(buf) && isDigit(buf[j]) {
+			j++
+		}
+	}
+	return j, true
+}
+
+// matchHex recognizes a "0x"/"0X"-prefixed hex literal.
+func matchHex(buf []byte, i int) (int, bool) {
+	if i+1 >= len(buf) || buf[i] != '0' || (buf[i+1] != 'x' && buf[i+1] != 'X') {
+		return i, false
+	}
+	j := i + 2
+	start := j
+	for j < len(buf) && isHexDigit(buf[j]) {
+		j++
+	}
+	if j == start {
+		return i, false
+	}
+	return j, true
+}
+
+// buildReplacers assembles the ordered Replacer list Preprocess drives over
+// the buffer: the PreprocessNumbers/PreprocessHex toggles first (for
+// backwards compatibility with callers that only set those), then whatever
+// opts.Replacers the caller asked for.
+func buildReplacers(opts TokenizerOpts) []Replacer {
+	var replacers []Replacer
+	if opts.PreprocessNumbers {
+		replacers = append(replacers, Replacer{Name: "number", Match: matchNumber, Placeholder: placeholderNumber})
+	}
+	if opts.PreprocessHex {
+		replacers = append(replacers, Replacer{Name: "hex", Match: matchHex, Placeholder: placeholderHex})
+	}
+	return append(replacers, opts.Replacers...)
+}
+
+// Preprocess substitutes runs recognized by opts' Replacers (numbers and hex
+// literals via PreprocessNumbers/PreprocessHex, plus any opts.Replacers)
+// with their placeholders, so that structurally identical log lines produce
+// identical tokens downstream. Replacers only fire at a token boundary (the
+// start of buf, or right after a preprocessBoundary byte), so a matcher
+// can't corrupt an identifier that happens to contain a UUID-shaped
+// substring. At a given position, the longest match among all replacers
+// wins.
+func Preprocess(buf []byte, opts TokenizerOpts) []byte {
+	replacers := buildReplacers(opts)
+	if len(replacers) == 0 {
+		return buf
+	}
+
+	out := make([]byte, 0, len(buf))
+	atBoundary := true
+	for i := 0; i < len(buf); {
+		if !atBoundary || preprocessBoundary[buf[i]] {
+			atBoundary = preprocessBoundary[buf[i]]
+			out = append(out, buf[i])
+			i++
+			continue
+		}
+
+		bestEnd, bestPlaceholder := -1, []byte(nil)
+		for _, r := range replacers {
+			if end, ok := r.Match(buf, i); ok && end > bestEnd {
+				bestEnd, bestPlaceholder = end, r.Placeholder
+			}
+		}
+
+		if bestEnd < 0 {
+			out = append(out, buf[i])
+			atBoundary = false
+			i++
+			continue
+		}
+
+		out = append(out, bestPlaceholder...)
+		i = bestEnd
+		atBoundary = i >= len(buf) || preprocessBoundary[buf[i]]
+	}
+
+	return out
+}
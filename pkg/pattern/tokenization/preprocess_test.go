@@ -0,0 +1,48 @@
+package tokenization
+
+import "testing"
+
+func TestPreprocessBoundaries(t *testing.T) {
+	opts := TokenizerOpts{PreprocessNumbers: true, Replacers: DefaultReplacers()}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "key=value pair",
+			in:   "ip=192.168.1.1 id=550e8400-e29b-41d4-a716-446655440000",
+			want: "ip=<IP> id=<UUID>",
+		},
+		{
+			name: "quoted JSON fields",
+			in:   `{"ip":"192.168.1.1","id":"550e8400-e29b-41d4-a716-446655440000"}`,
+			want: `{"ip":"<IP>","id":"<UUID>"}`,
+		},
+		{
+			name: "comma-separated values",
+			in:   "192.168.1.1,550e8400-e29b-41d4-a716-446655440000",
+			want: "<IP>,<UUID>",
+		},
+		{
+			name: "value after whitespace still works",
+			in:   "request from 192.168.1.1 took 250ms",
+			want: "request from <IP> took <DURATION>",
+		},
+		{
+			name: "parenthesized and bracketed values",
+			in:   "(192.168.1.1)[550e8400-e29b-41d4-a716-446655440000]",
+			want: "(<IP>)[<UUID>]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(Preprocess([]byte(tc.in), opts))
+			if got != tc.want {
+				t.Fatalf("Preprocess(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package tokenization
+
+import "bytes"
+
+var (
+	placeholderIP       = []byte("<IP>")
+	placeholderUUID     = []byte("<UUID>")
+	placeholderURL      = []byte("<URL>")
+	placeholderEmail    = []byte("<EMAIL>")
+	placeholderDuration = []byte("<DURATION>")
+	placeholderRFC3339  = []byte("<TIME>")
+)
+
+// Built-in Replacers for the log fields that show up often enough in
+// production templates to be worth collapsing on their own: addresses,
+// identifiers, locators, and the two time-ish shapes Go code tends to emit.
+// DefaultReplacers bundles all of them.
+var (
+	ReplacerIPv4     = Replacer{Name: "ipv4", Match: matchIPv4, Placeholder: placeholderIP}
+	ReplacerIPv6     = Replacer{Name: "ipv6", Match: matchIPv6, Placeholder: placeholderIP}
+	ReplacerUUID     = Replacer{Name: "uuid", Match: matchUUID, Placeholder: placeholderUUID}
+	ReplacerURL      = Replacer{Name: "url", Match: matchURL, Placeholder: placeholderURL}
+	ReplacerEmail    = Replacer{Name: "email", Match: matchEmail, Placeholder: placeholderEmail}
+	ReplacerDuration = Replacer{Name: "duration", Match: matchDuration, Placeholder: placeholderDuration}
+	ReplacerRFC3339  = Replacer{Name: "rfc3339", Match: matchRFC3339, Placeholder: placeholderRFC3339}
+)
+
+// DefaultReplacers returns the built-in Replacer set PreprocessAndTokenize
+// enables: IPv4/IPv6 addresses, UUIDs, URLs, email addresses, RFC3339
+// timestamps, and Go-style durations.
+func DefaultReplacers() []Replacer {
+	return []Replacer{
+		ReplacerRFC3339,
+		ReplacerUUID,
+		ReplacerIPv6,
+		ReplacerIPv4,
+		ReplacerURL,
+		ReplacerEmail,
+		ReplacerDuration,
+	}
+}
+
+// matchIPv4 recognizes four 1-3 digit groups separated by '.', e.g.
+// "10.0.0.1". It doesn't validate that each group is <= 255, matching the
+// rest of this package's "good enough for template extraction" heuristics.
+func matchIPv4(buf []byte, i int) (int, bool) {
+	j := i
+	for g := 0; g < 4; g++ {
+		if g > 0 {
+			if j >= len(buf) || buf[j] != '.' {
+				return i, false
+			}
+			j++
+		}
+		start := j
+		for j < len(buf) && j-start < 3 && isDigit(buf[j]) {
+			j++
+		}
+		if j == start {
+			return i, false
+		}
+	}
+	return j, true
+}
+
+// matchIPv6 recognizes a run of hex digits and colons containing at least
+// two colons, e.g. "fe80::1" or "2001:db8::ff00:42:8329".
+func matchIPv6(buf []byte, i int) (int, bool) {
+	j, colons := i, 0
+	for j < len(buf) && (isHexDigit(buf[j]) || buf[j] == ':') {
+		if buf[j] == ':' {
+			colons++
+		}
+		j++
+	}
+	if colons < 2 {
+		return i, false
+	}
+	return j, true
+}
+
+var uuidGroupLens = [5]int{8, 4, 4, 4, 12}
+
+// matchUUID recognizes the canonical 8-4-4-4-12 hyphenated hex UUID form.
+func matchUUID(buf []byte, i int) (int, bool) {
+	j := i
+	for g, n := range uuidGroupLens {
+		if g > 0 {
+			if j >= len(buf) || buf[j] != '-' {
+				return i, false
+			}
+			j++
+		}
+		for k := 0; k < n; k++ {
+			if j >= len(buf) || !isHexDigit(buf[j]) {
+				return i, false
+			}
+			j++
+		}
+	}
+	return j, true
+}
+
+var urlSchemes = [][]byte{[]byte("https://"), []byte("http://"), []byte("ftp://")}
+
+// urlBoundary is what actually ends a URL: delimiters[] plus the
+// punctuation that closes it off in context (a surrounding quote, or a
+// container/list terminator). Unlike preprocessBoundary, it deliberately
+// excludes ':', '=', '(' and '[', since ports, query strings and fragments
+// routinely contain them.
+var urlBoundary = func() [256]bool {
+	b := delimiters
+	for _, c := range []byte{'"', '\'', '`', ',', ')', ']', '}'} {
+		b[c] = true
+	}
+	return b
+}()
+
+// matchURL recognizes "scheme://" followed by the rest of the token, up to
+// whatever actually ends a URL rather than Preprocess's general token
+// boundary, since a URL routinely contains ':', '=' and '(' in its own
+// right (ports, query strings, fragments).
+func matchURL(buf []byte, i int) (int, bool) {
+	for _, scheme := range urlSchemes {
+		if bytes.HasPrefix(buf[i:], scheme) {
+			j := i + len(scheme)
+			for j < len(buf) && !urlBoundary[buf[j]] {
+				j++
+			}
+			return j, true
+		}
+	}
+	return i, false
+}
+
+// matchEmail recognizes "local@domain.tld", requiring a non-empty local
+// part and at least one '.' in the domain.
+func matchEmail(buf []byte, i int) (int, bool) {
+	j := i
+	for j < len(buf) && !preprocessBoundary[buf[j]] && buf[j] != '@' {
+		j++
+	}
+	if j == i || j >= len(buf) || buf[j] != '@' {
+		return i, false
+	}
+	j++
+	domainStart := j
+	for j < len(buf) && (isAlphaNum(buf[j]) || buf[j] == '.' || buf[j] == '-') {
+		j++
+	}
+	if j == domainStart || bytes.IndexByte(buf[domainStart:j], '.') < 0 {
+		return i, false
+	}
+	return j, true
+}
+
+var durationUnits = [][]byte{[]byte("ns"), []byte("us"), []byte("µs"), []byte("ms"), []byte("s"), []byte("m"), []byte("h")}
+
+func matchDurationUnit(buf []byte, i int) (int, bool) {
+	best := -1
+	for _, u := range durationUnits {
+		if bytes.HasPrefix(buf[i:], u) && len(u) > best {
+			best = len(u)
+		}
+	}
+	if best < 0 {
+		return i, false
+	}
+	return i + best, true
+}
+
+// matchDuration recognizes Go-style durations: one or more number+unit
+// pairs back to back, e.g. "1.5s", "250ms", "2h3m10s".
+func matchDuration(buf []byte, i int) (int, bool) {
+	j, matchedAny := i, false
+	for {
+		start := j
+		for j < len(buf) && isDigit(buf[j]) {
+			j++
+		}
+		if j < len(buf) && buf[j] == '.' && j+1 < len(buf) && isDigit(buf[j+1]) {
+			j++
+			for j < len(buf) && isDigit(buf[j]) {
+				j++
+			}
+		}
+		if j == start {
+			break
+		}
+		end, ok := matchDurationUnit(buf, j)
+		if !ok {
+			return i, false
+		}
+		j, matchedAny = end, true
+		if j >= len(buf) || !isDigit(buf[j]) {
+			break
+		}
+	}
+	if !matchedAny {
+		return i, false
+	}
+	return j, true
+}
+
+func matchDigits(buf []byte, i, n int) (int, bool) {
+	for k := 0; k < n; k++ {
+		if i+k >= len(buf) || !isDigit(buf[i+k]) {
+			return i, false
+		}
+	}
+	return i + n, true
+}
+
+// matchRFC3339 recognizes an RFC3339 timestamp: "2006-01-02T15:04:05",
+// optionally with fractional seconds, optionally followed by "Z" or a
+// "+HH:MM" (or "+HH") offset. A timestamp with no zone also matches.
+func matchRFC3339(buf []byte, i int) (int, bool) {
+	j := i
+	for g, n := range [3]int{4, 2, 2} {
+		if g > 0 {
+			if j >= len(buf) || buf[j] != '-' {
+				return i, false
+			}
+			j++
+		}
+		end, ok := matchDigits(buf, j, n)
+		if !ok {
+			return i, false
+		}
+		j = end
+	}
+	if j >= len(buf) || (buf[j] != 'T' && buf[j] != 't') {
+		return i, false
+	}
+	j++
+	for g, n := range [3]int{2, 2, 2} {
+		if g > 0 {
+			if j >= len(buf) || buf[j] != ':' {
+				return i, false
+			}
+			j++
+		}
+		end, ok := matchDigits(buf, j, n)
+		if !ok {
+			return i, false
+		}
+		j = end
+	}
+	if j < len(buf) && buf[j] == '.' {
+		start := j + 1
+		end, ok := matchDigits(buf, start, 1)
+		if !ok {
+			return i, false
+		}
+		j = end
+		for j < len(buf) && isDigit(buf[j]) {
+			j++
+		}
+	}
+	if j < len(buf) {
+		switch buf[j] {
+		case 'Z', 'z':
+			j++
+		case '+', '-':
+			end, ok := matchDigits(buf, j+1, 2)
+			if !ok {
+				return i, false
+			}
+			j = end
+			if j < len(buf) && buf[j] == ':' {
+				end, ok := matchDigits(buf, j+1, 2)
+				if !ok {
+					return i, false
+				}
+				j = end
+			}
+		}
+	}
+	return j, true
+}
@@ -0,0 +1,60 @@
+package tokenization
+
+import "testing"
+
+// TestReplacersBoundaryContexts checks each built-in Replacer fires not just
+// on a standalone value, but also unquoted in a key=value pair and quoted in
+// a JSON field (and, for IPv4, as one of several comma-separated values) -
+// the contexts Preprocess's boundary predicate previously missed.
+func TestReplacersBoundaryContexts(t *testing.T) {
+	opts := TokenizerOpts{Replacers: DefaultReplacers()}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ipv4 standalone", "10.0.0.1", "<IP>"},
+		{"ipv4 key=value", "addr=10.0.0.1", "addr=<IP>"},
+		{"ipv4 quoted JSON field", `{"addr":"10.0.0.1"}`, `{"addr":"<IP>"}`},
+		{"ipv4 comma list", "10.0.0.1,10.0.0.2", "<IP>,<IP>"},
+
+		{"ipv6 standalone", "fe80::1", "<IP>"},
+		{"ipv6 key=value", "addr=fe80::1", "addr=<IP>"},
+		{"ipv6 quoted JSON field", `{"addr":"fe80::1"}`, `{"addr":"<IP>"}`},
+
+		{"uuid standalone", "550e8400-e29b-41d4-a716-446655440000", "<UUID>"},
+		{"uuid key=value", "id=550e8400-e29b-41d4-a716-446655440000", "id=<UUID>"},
+		{"uuid quoted JSON field", `{"id":"550e8400-e29b-41d4-a716-446655440000"}`, `{"id":"<UUID>"}`},
+
+		{"url standalone", "https://example.com/path", "<URL>"},
+		{"url key=value", "ref=https://example.com/path", "ref=<URL>"},
+		{"url quoted JSON field", `{"ref":"https://example.com/path"}`, `{"ref":"<URL>"}`},
+		{"url with port", "http://example.com:8080/path", "<URL>"},
+		{"url with query string", "http://example.com/path?a=1&b=2", "<URL>"},
+		{"url with port, query and fragment, quoted", `{"ref":"http://example.com:8080/path?a=1&b=2#frag"}`, `{"ref":"<URL>"}`},
+		{"url followed by comma", "see http://example.com:8080/path, done", "see <URL>, done"},
+		{"url in parens", "(http://example.com/path)", "(<URL>)"},
+
+		{"email standalone", "user@example.com", "<EMAIL>"},
+		{"email key=value", "from=user@example.com", "from=<EMAIL>"},
+		{"email quoted JSON field", `{"from":"user@example.com"}`, `{"from":"<EMAIL>"}`},
+
+		{"duration standalone", "250ms", "<DURATION>"},
+		{"duration key=value", "elapsed=250ms", "elapsed=<DURATION>"},
+		{"duration quoted JSON field", `{"elapsed":"250ms"}`, `{"elapsed":"<DURATION>"}`},
+
+		{"rfc3339 standalone", "2024-01-02T15:04:05Z", "<TIME>"},
+		{"rfc3339 key=value", "at=2024-01-02T15:04:05Z", "at=<TIME>"},
+		{"rfc3339 quoted JSON field", `{"at":"2024-01-02T15:04:05Z"}`, `{"at":"<TIME>"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(Preprocess([]byte(tc.in), opts))
+			if got != tc.want {
+				t.Fatalf("Preprocess(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package tokenization
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	scannerMinBufSize = 8 * 1024
+	scannerMaxBufSize = 32 * 1024
+)
+
+// errTokenTooLarge is returned by fill when a single token doesn't fit even
+// after growing the internal buffer up to scannerMaxBufSize. Scan never
+// reports it through Err: it emits a TokenEOL token instead (see
+// overflowToken).
+var errTokenTooLarge = errors.New("tokenization: token exceeds scanner max buffer size")
+
+// Scanner tokenizes an io.Reader incrementally, the way bufio.Scanner
+// tokenizes lines: outside of StructuredJSON mode, which buffers a whole JSON
+// value before walking it, it never needs a whole record buffered up front,
+// and it runs the state machine over the input only once, with no separate
+// pass to size a result slice. It's meant for ingestion paths (stdin
+// tailers, socket tailers, very long multi-line records) where allocating a
+// []byte per line adds up.
+//
+// Bytes() aliases the Scanner's internal buffer and is only valid until the
+// next call to Scan, exactly like bufio.Scanner. A Scanner is not safe for
+// concurrent use, but Reset lets one be recycled (e.g. via a sync.Pool)
+// across readers instead of allocating a new buffer per line.
+type Scanner struct {
+	r    io.Reader
+	opts TokenizerOpts
+
+	buf      []byte
+	pos      int // scan cursor
+	end      int // end of valid data in buf
+	tokStart int // start of the token currently being built
+	absBase  int // bytes permanently discarded from the front of buf by fill
+	eof      bool
+
+	// Per-record tokenizer state, kept on the Scanner rather than on the
+	// stack so that it survives buffer refills.
+	escaped      bool
+	curQuoteChar byte
+	curQuotePos  int
+	maybeJSON    bool
+	sawQuote     bool // the token currently being built opened a quote
+
+	recordTokens int  // tokens emitted for the record currently being scanned
+	skipToEOL    bool // MaxTokens was reached; discarding the rest of the record
+
+	// StructuredJSON state: once jsonMode is set, nextToken defers entirely
+	// to nextJSONToken for the rest of the value spanning [tokStart, jsonEnd).
+	jsonMode      bool
+	jsonAttempted bool
+	jsonEnd       int
+	jsonPos       int
+	jsonStack     []jsonFrame
+
+	tok          []byte
+	tokTyp       TokenType
+	tokStartAbs  int
+	tokEndAbs    int
+	err          error
+}
+
+// NewScanner returns a Scanner that reads from r and tokenizes it according
+// to opts.
+func NewScanner(r io.Reader, opts TokenizerOpts) *Scanner {
+	s := &Scanner{opts: opts}
+	s.Reset(r)
+	return s
+}
+
+// Reset recycles the Scanner to read from r, discarding any buffered state.
+// It lets callers pool Scanners (one per tailed file/connection) instead of
+// allocating a new buffer for every reader.
+func (s *Scanner) Reset(r io.Reader) {
+	if s.buf == nil {
+		s.buf = make([]byte, scannerMinBufSize)
+	}
+	s.r = r
+	s.pos, s.end, s.tokStart, s.absBase = 0, 0, 0, 0
+	s.eof = false
+	s.escaped = false
+	s.curQuoteChar, s.curQuotePos = 0, -1
+	s.maybeJSON = false
+	s.sawQuote = false
+	s.recordTokens = 0
+	s.skipToEOL = false
+	s.jsonMode, s.jsonAttempted = false, false
+	s.jsonStack = s.jsonStack[:0]
+	s.tok, s.err = nil, nil
+	s.tokTyp = TokenWord
+}
+
+// Bytes returns the most recently scanned token. The returned slice aliases
+// the Scanner's internal buffer and is invalidated by the next call to Scan.
+func (s *Scanner) Bytes() []byte { return s.tok }
+
+// Type returns the classification of the most recently scanned token.
+func (s *Scanner) Type() TokenType { return s.tokTyp }
+
+// Start and End return the byte offsets of the most recently scanned token
+// within the stream read so far. A TokenEOL token doesn't update them: they
+// still hold the offsets of the token before it.
+func (s *Scanner) Start() int { return s.tokStartAbs }
+func (s *Scanner) End() int   { return s.tokEndAbs }
+
+// Err returns the first non-EOF error encountered while reading.
+func (s *Scanner) Err() error { return s.err }
+
+func (s *Scanner) maxTokens() int {
+	if s.opts.MaxTokens == 0 {
+		return 100
+	}
+	return s.opts.MaxTokens
+}
+
+// Scan advances the Scanner to the next token, returning false once the
+// underlying reader is exhausted or a read error occurs.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		if tok, typ, ok := s.nextToken(); ok {
+			s.tok, s.tokTyp = tok, typ
+			return true
+		}
+		if s.eof {
+			return false
+		}
+		if err := s.fill(); err != nil {
+			if err == errTokenTooLarge {
+				s.tok, s.tokTyp = s.overflowToken()
+				return true
+			}
+			s.err = err
+			return false
+		}
+	}
+}
+
+// overflowToken is reached when fill can't grow the buffer enough to find
+// the end of whatever's being scanned (an unbroken run with no delimiter,
+// or a JSON value, bigger than scannerMaxBufSize). Rather than surfacing
+// errTokenTooLarge as a terminal error - which silently truncated the rest
+// of the record for callers that don't check Err(), like
+// PreprocessAndTokenizeBytesWithOpts - it's treated the same way a MaxTokens
+// overflow already is: emit the EOL sentinel and discard the rest of the
+// record up to the next newline.
+func (s *Scanner) overflowToken() ([]byte, TokenType) {
+	s.jsonMode = false
+	s.tokStart, s.pos = s.end, s.end
+	s.skipToEOL = true
+	return placeholderEndOfLine, TokenEOL
+}
+
+// fill compacts the in-progress token to the front of buf, growing it if
+// necessary, then reads more data from r.
+func (s *Scanner) fill() error {
+	if s.tokStart > 0 {
+		s.absBase += s.tokStart
+		n := copy(s.buf, s.buf[s.tokStart:s.end])
+		s.pos -= s.tokStart
+		s.end = n
+		s.tokStart = 0
+	}
+	if s.end == len(s.buf) {
+		if len(s.buf) >= scannerMaxBufSize {
+			return errTokenTooLarge
+		}
+		grown := make([]byte, len(s.buf)*2)
+		copy(grown, s.buf[:s.end])
+		s.buf = grown
+	}
+	n, err := s.r.Read(s.buf[s.end:])
+	s.end += n
+	if err != nil {
+		if err == io.EOF {
+			s.eof = true
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// startRecord resets the per-line state tracked by the tokenizer. It's
+// called at a '\n' delimiter (or after discarding an overflowing record),
+// the closest thing a continuous token stream has to the start of a new
+// call to PreprocessAndTokenize.
+func (s *Scanner) startRecord() {
+	s.recordTokens = 0
+	s.maybeJSON = false
+	s.curQuotePos = -1
+	s.escaped = false
+	s.jsonAttempted = false
+}
+
+// nextToken runs the byte-level tokenizer state machine over a rolling
+// buffer instead of a single in-memory line: it returns ok=false instead of
+// blocking when it runs off the end of buffered data, and Scan refills and
+// retries in that case.
+func (s *Scanner) nextToken() ([]byte, TokenType, bool) {
+	if s.jsonMode {
+		return s.nextJSONToken()
+	}
+
+	if s.opts.StructuredJSON && !s.jsonAttempted && s.recordTokens == 0 && s.tokStart < s.end &&
+		(s.buf[s.tokStart] == '{' || s.buf[s.tokStart] == '[') {
+		s.jsonAttempted = true
+		switch entered, needMore := s.tryEnterJSONMode(); {
+		case needMore:
+			s.jsonAttempted = false
+			return nil, 0, false
+		case entered:
+			return s.nextJSONToken()
+		}
+	}
+
+	if s.skipToEOL {
+		for s.pos < s.end && s.buf[s.pos] != '\n' {
+			s.pos++
+		}
+		if s.pos >= s.end && !s.eof {
+			return nil, 0, false
+		}
+		if s.pos < s.end {
+			s.pos++
+		}
+		s.tokStart = s.pos
+		s.skipToEOL = false
+		s.startRecord()
+	}
+
+	// MaxTokens-1 tokens were already emitted for this record and more input
+	// remains: emit the sentinel as the record's last token and arrange for
+	// the remainder of the record to be discarded.
+	if s.recordTokens >= s.maxTokens()-1 && s.tokStart < s.end {
+		s.skipToEOL = true
+		return placeholderEndOfLine, TokenEOL, true
+	}
+
+	for p := s.pos; p < s.end; p++ {
+		if p == s.tokStart && s.recordTokens == 0 {
+			s.maybeJSON = s.buf[p] == '{'
+		}
+		c := s.buf[p]
+		switch {
+		case s.escaped:
+			if s.curQuotePos < 0 && delimiters[c] {
+				s.pos = p
+				return s.emitDelim(c)
+			}
+			s.escaped = false
+
+		case c == '\\':
+			s.escaped = true
+
+		case c > 127:
+			// Part of the current token, nothing to do.
+
+		case s.opts.UseSingleTokenForQuotes && s.curQuotePos >= 0:
+			if c == s.curQuoteChar {
+				s.curQuotePos = -1
+			}
+
+		case s.opts.UseSingleTokenForQuotes && (c == '"' || c == '\'' || c == '`'):
+			s.curQuoteChar = c
+			s.curQuotePos = p
+			s.sawQuote = true
+
+		case delimiters[c]:
+			s.pos = p
+			return s.emitDelim(c)
+
+		case s.maybeJSON && p > s.tokStart && (c == ':' || c == ',') && p+1 < s.end:
+			if c == ':' && s.buf[p-1] == '"' && !delimiters[s.buf[p+1]] {
+				s.pos = p
+				return s.emit(p+1, 0, TokenJSONKey)
+			}
+			if c == ',' && s.buf[p+1] == '"' {
+				s.pos = p
+				return s.emit(p, 0, TokenWord)
+			}
+		}
+	}
+	s.pos = s.end
+
+	if !s.eof {
+		return nil, 0, false
+	}
+
+	if s.curQuotePos > s.tokStart {
+		return s.emit(s.curQuotePos+1, 0, TokenWord)
+	}
+	if s.tokStart < s.end {
+		return s.emit(s.end, 0, TokenWord)
+	}
+	return nil, 0, false
+}
+
+func (s *Scanner) emitDelim(c byte) ([]byte, TokenType, bool) {
+	end, skip := s.pos, 1
+	if s.opts.IncludeDelimitersInTokens {
+		end, skip = s.pos+1, 0
+	}
+	tok, typ, ok := s.emit(end, skip, TokenWord)
+	if c == '\n' {
+		s.startRecord()
+	}
+	return tok, typ, ok
+}
+
+func (s *Scanner) emit(end, skip int, typ TokenType) ([]byte, TokenType, bool) {
+	tok := s.buf[s.tokStart:end]
+	s.tokStartAbs, s.tokEndAbs = s.absBase+s.tokStart, s.absBase+end
+	s.tokStart = end + skip
+	s.pos = s.tokStart
+	s.recordTokens++
+
+	if typ == TokenWord {
+		typ = s.classify(tok)
+	}
+	s.sawQuote = false
+	return tok, typ, true
+}
+
+// classify assigns a TokenType to a token the state machine didn't already
+// have an explicit type for (TokenJSONKey, TokenEOL), based on state the
+// scan loop tracked while building it.
+func (s *Scanner) classify(tok []byte) TokenType {
+	switch {
+	case s.sawQuote:
+		return TokenQuotedString
+	case s.opts.IncludeDelimitersInTokens && len(tok) == 1 && delimiters[tok[0]]:
+		// Only reached when this delimiter had nothing preceding it to
+		// attach to, i.e. it immediately follows another delimiter.
+		return TokenDelimiter
+	case bytes.Equal(tok, placeholderNumber):
+		return TokenNumberPlaceholder
+	case bytes.Equal(tok, placeholderHex):
+		return TokenHexPlaceholder
+	default:
+		return TokenWord
+	}
+}
+
+// jsonFrame tracks one open container of a StructuredJSON walk: whether it's
+// an object or array, and, for objects, whether the key of the current
+// key/value pair has already been emitted.
+type jsonFrame struct {
+	container byte
+	sawKey    bool
+}
+
+// tryEnterJSONMode attempts to resolve the full extent of the JSON value
+// starting at s.tokStart using the consumeXXX scanner, growing the buffer
+// via fill as needed. It never mutates scan position: on failure the caller
+// falls back to the ordinary byte-level loop starting from the same offset.
+func (s *Scanner) tryEnterJSONMode() (entered, needMore bool) {
+	end, err := consumeValue(s.buf[:s.end], s.tokStart)
+	switch err {
+	case nil:
+		s.jsonMode = true
+		s.jsonEnd = end
+		s.jsonPos = s.tokStart
+		s.jsonStack = s.jsonStack[:0]
+		return true, false
+	case errJSONTruncated:
+		return false, !s.eof
+	default:
+		return false, false
+	}
+}
+
+// nextJSONToken walks the JSON value resolved by tryEnterJSONMode one token
+// at a time: '{'/'}'/'['/']' as TokenJSONPunct, `"key":` as TokenJSONKey,
+// and scalars (unquoting strings) as TokenWord. The whole value is already
+// buffered, so unlike nextToken this never needs to request more data.
+func (s *Scanner) nextJSONToken() ([]byte, TokenType, bool) {
+	if s.recordTokens >= s.maxTokens()-1 {
+		s.jsonMode = false
+		s.tokStart, s.pos = s.jsonEnd, s.jsonEnd
+		s.skipToEOL = true
+		return placeholderEndOfLine, TokenEOL, true
+	}
+
+	s.jsonPos = skipJSONSpace(s.buf[:s.end], s.jsonPos)
+	if s.jsonPos < s.jsonEnd && s.buf[s.jsonPos] == ',' {
+		s.jsonPos = skipJSONSpace(s.buf[:s.end], s.jsonPos+1)
+	}
+
+	if s.jsonPos >= s.jsonEnd {
+		s.jsonMode = false
+		s.tokStart, s.pos = s.jsonEnd, s.jsonEnd
+		return s.nextToken()
+	}
+
+	switch c := s.buf[s.jsonPos]; c {
+	case '{', '[':
+		if n := len(s.jsonStack); n > 0 && s.jsonStack[n-1].container == '{' {
+			s.jsonStack[n-1].sawKey = false
+		}
+		s.jsonStack = append(s.jsonStack, jsonFrame{container: c})
+		return s.emitJSON(s.jsonPos, s.jsonPos+1, TokenJSONPunct)
+
+	case '}', ']':
+		if n := len(s.jsonStack); n > 0 {
+			s.jsonStack = s.jsonStack[:n-1]
+		}
+		return s.emitJSON(s.jsonPos, s.jsonPos+1, TokenJSONPunct)
+	}
+
+	var top jsonFrame
+	if n := len(s.jsonStack); n > 0 {
+		top = s.jsonStack[n-1]
+	}
+
+	if top.container == '{' && !top.sawKey {
+		end, err := consumeString(s.buf[:s.end], s.jsonPos)
+		if err != nil {
+			return s.abortJSON()
+		}
+		colon := skipJSONSpace(s.buf[:s.end], end)
+		if colon >= s.jsonEnd || s.buf[colon] != ':' {
+			return s.abortJSON()
+		}
+		s.jsonStack[len(s.jsonStack)-1].sawKey = true
+		return s.emitJSON(s.jsonPos, colon+1, TokenJSONKey)
+	}
+
+	start := s.jsonPos
+	end, err := consumeValue(s.buf[:s.end], start)
+	if err != nil {
+		return s.abortJSON()
+	}
+	if n := len(s.jsonStack); n > 0 && s.jsonStack[n-1].container == '{' {
+		s.jsonStack[n-1].sawKey = false
+	}
+	if s.buf[start] == '"' {
+		// Emit without the surrounding quotes, but still advance past the
+		// closing quote (the real value end), not just the unquoted body -
+		// otherwise the next token would start on the quote itself.
+		tok, typ, ok := s.emitJSON(start+1, end-1, TokenWord)
+		s.jsonPos, s.tokStart, s.pos = end, end, end
+		return tok, typ, ok
+	}
+	return s.emitJSON(start, end, TokenWord)
+}
+
+// abortJSON bails out of a structured walk that failed after having already
+// been entered (the upfront consumeValue in tryEnterJSONMode should make
+// this unreachable in practice) and resumes byte-level scanning from the end
+// of the last token the walk emitted, rather than discarding the record.
+func (s *Scanner) abortJSON() ([]byte, TokenType, bool) {
+	s.jsonMode = false
+	s.pos = s.tokStart
+	return s.nextToken()
+}
+
+func (s *Scanner) emitJSON(start, end int, typ TokenType) ([]byte, TokenType, bool) {
+	tok := s.buf[start:end]
+	s.tokStartAbs, s.tokEndAbs = s.absBase+start, s.absBase+end
+	s.jsonPos = end
+	s.tokStart, s.pos = end, end
+	s.recordTokens++
+	if typ == TokenWord {
+		typ = s.classify(tok)
+	}
+	return tok, typ, true
+}
+
+// PreprocessAndTokenize splits content on whitespace-ish delimiters, folding
+// runs of digits and hex into placeholders so that structurally identical
+// log lines produce identical tokens. It's implemented on top of Scanner,
+// reading a single in-memory line instead of a live stream.
+func PreprocessAndTokenize(content []byte) []string {
+	return PreprocessAndTokenizeStringWithOpts(content, TokenizerOpts{
+		MaxTokens:                 100,
+		UseSingleTokenForQuotes:   true,
+		IncludeDelimitersInTokens: false,
+		PreprocessNumbers:         true,
+		PreprocessHex:             true,
+		Replacers:                 DefaultReplacers(),
+	})
+}
+
+func PreprocessAndTokenizeStringWithOpts(content []byte, opts TokenizerOpts) []string {
+	tokens := PreprocessAndTokenizeBytesWithOpts(content, opts)
+	stringTokens := make([]string, len(tokens))
+	for i, token := range tokens {
+		stringTokens[i] = string(token)
+	}
+	return stringTokens
+}
+
+func PreprocessAndTokenizeBytesWithOpts(content []byte, opts TokenizerOpts) [][]byte {
+	content = bytes.TrimSpace(content)
+	content = Preprocess(content, opts)
+
+	// Tokens reference content and shouldn't need a new allocation per
+	// token: Start()/End() are absolute offsets into the stream Scanner is
+	// reading, which here is content itself, so we can slice it directly
+	// instead of copying out of Scanner's internal (and reused/compacted)
+	// buffer. TokenEOL is the one exception, since it's a synthetic
+	// placeholder Scanner never assigns a Start/End to.
+	s := NewScanner(bytes.NewReader(content), opts)
+	tokens := make([][]byte, 0, 16)
+	for s.Scan() {
+		if s.Type() == TokenEOL {
+			tokens = append(tokens, s.Bytes())
+			continue
+		}
+		tokens = append(tokens, content[s.Start():s.End()])
+	}
+	return tokens
+}
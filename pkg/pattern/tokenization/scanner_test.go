@@ -0,0 +1,205 @@
+package tokenization
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// chunkReader hands back at most n bytes per Read, regardless of how much
+// space the caller offers, to exercise Scanner's fill/compaction path the
+// way a socket or pipe would.
+type chunkReader struct {
+	data []byte
+	pos  int
+	n    int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.EOF
+	}
+	n := r.n
+	if n > len(p) {
+		n = len(p)
+	}
+	if r.pos+n > len(r.data) {
+		n = len(r.data) - r.pos
+	}
+	copy(p, r.data[r.pos:r.pos+n])
+	r.pos += n
+	return n, nil
+}
+
+type scannedToken struct {
+	value string
+	typ   TokenType
+}
+
+func scanAll(t *testing.T, r io.Reader, opts TokenizerOpts) []scannedToken {
+	t.Helper()
+	s := NewScanner(r, opts)
+	var got []scannedToken
+	for s.Scan() {
+		got = append(got, scannedToken{value: string(s.Bytes()), typ: s.Type()})
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Scan error: %v", err)
+	}
+	return got
+}
+
+// TestScannerStructuredJSONAcrossPartialReads drives two JSON records through
+// a reader that only ever returns 3 bytes per Read, forcing fill() to
+// compact and refill the buffer mid-record. Both records must parse cleanly
+// and independently: a prior bug let stale bytes beyond the valid portion of
+// the buffer leak into the JSON scan, corrupting the first record's closing
+// punctuation and silently breaking StructuredJSON mode for everything after
+// it.
+func TestScannerStructuredJSONAcrossPartialReads(t *testing.T) {
+	input := "{\"a\":\"192\"}\n{\"a\":\"B\"}\n"
+	r := &chunkReader{data: []byte(input), n: 3}
+	opts := TokenizerOpts{StructuredJSON: true, MaxTokens: 100}
+
+	got := scanAll(t, r, opts)
+	want := []scannedToken{
+		{"{", TokenJSONPunct},
+		{`"a":`, TokenJSONKey},
+		{"192", TokenWord},
+		{"}", TokenJSONPunct},
+		{"", TokenWord},
+		{"{", TokenJSONPunct},
+		{`"a":`, TokenJSONKey},
+		{"B", TokenWord},
+		{"}", TokenJSONPunct},
+		{"", TokenWord},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestScannerStructuredJSONSingleShot is the same two-record input read in
+// one shot, as a baseline the partial-read case above must match.
+func TestScannerStructuredJSONSingleShot(t *testing.T) {
+	input := "{\"a\":\"192\"}\n{\"a\":\"B\"}\n"
+	opts := TokenizerOpts{StructuredJSON: true, MaxTokens: 100}
+
+	chunked := scanAll(t, &chunkReader{data: []byte(input), n: 3}, opts)
+	wholeShot := scanAll(t, bytes.NewReader([]byte(input)), opts)
+
+	if len(chunked) != len(wholeShot) {
+		t.Fatalf("partial-read scan produced %d tokens, single-shot produced %d", len(chunked), len(wholeShot))
+	}
+	for i := range wholeShot {
+		if chunked[i] != wholeShot[i] {
+			t.Errorf("token %d: partial-read got %+v, single-shot got %+v", i, chunked[i], wholeShot[i])
+		}
+	}
+}
+
+// TestPreprocessAndTokenizeBytesWithOptsValues exercises the in-memory
+// helper's happy path plus the MaxTokens/TokenEOL truncation edge case,
+// since tokens there come from two different sources (slices of content,
+// and the fixed placeholderEndOfLine sentinel) that must not be confused.
+func TestPreprocessAndTokenizeBytesWithOptsValues(t *testing.T) {
+	opts := TokenizerOpts{
+		UseSingleTokenForQuotes: true,
+		PreprocessNumbers:       true,
+		PreprocessHex:           true,
+		Replacers:               DefaultReplacers(),
+	}
+	got := PreprocessAndTokenizeBytesWithOpts([]byte(`key="val ue" 42 0x1F request from 10.0.0.1`), opts)
+	want := [][]byte{[]byte(`key="val ue"`), []byte("<NUM>"), []byte("<HEX>"), []byte("request"), []byte("from"), []byte("<IP>")}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %q, want %d tokens %q", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if string(got[i]) != string(want[i]) {
+			t.Errorf("token %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	truncated := PreprocessAndTokenizeBytesWithOpts([]byte("a b c d e"), TokenizerOpts{MaxTokens: 3})
+	wantTruncated := [][]byte{[]byte("a"), []byte("b"), placeholderEndOfLine}
+	if len(truncated) != len(wantTruncated) {
+		t.Fatalf("got %d tokens %q, want %d tokens %q", len(truncated), truncated, len(wantTruncated), wantTruncated)
+	}
+	for i := range wantTruncated {
+		if string(truncated[i]) != string(wantTruncated[i]) {
+			t.Errorf("token %d: got %q, want %q", i, truncated[i], wantTruncated[i])
+		}
+	}
+}
+
+// TestScannerOversizedTokenResyncs checks that an unbroken run longer than
+// scannerMaxBufSize doesn't get silently truncated: it should emit the same
+// TokenEOL sentinel a MaxTokens overflow does and resync at the next record,
+// rather than surfacing errTokenTooLarge as a terminal Err() that callers
+// like PreprocessAndTokenizeBytesWithOpts never check.
+func TestScannerOversizedTokenResyncs(t *testing.T) {
+	blob := strings.Repeat("a", scannerMaxBufSize+10000)
+	input := "prefix " + blob + " suffix\nnext line\n"
+
+	got := PreprocessAndTokenizeBytesWithOpts([]byte(input), TokenizerOpts{})
+	want := [][]byte{[]byte("prefix"), placeholderEndOfLine, []byte("next"), []byte("line")}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens, want %d tokens %q", len(got), len(want), want)
+	}
+	for i := range want {
+		if string(got[i]) != string(want[i]) {
+			t.Errorf("token %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	s := NewScanner(bytes.NewReader([]byte(input)), TokenizerOpts{})
+	for s.Scan() {
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v, want nil", err)
+	}
+}
+
+// TestScannerPlainTextAcrossPartialReads drives ordinary (non-JSON)
+// multi-line input through a reader that only ever returns 2 bytes per
+// Read, so a token, a quoted string, and a line boundary can each land
+// split across multiple fill() calls.
+func TestScannerPlainTextAcrossPartialReads(t *testing.T) {
+	input := "foo bar=\"baz qux\"\nnext line here\n"
+	opts := TokenizerOpts{UseSingleTokenForQuotes: true, MaxTokens: 100}
+
+	chunked := scanAll(t, &chunkReader{data: []byte(input), n: 2}, opts)
+	wholeShot := scanAll(t, bytes.NewReader([]byte(input)), opts)
+
+	if len(chunked) != len(wholeShot) {
+		t.Fatalf("partial-read scan produced %d tokens %+v, single-shot produced %d tokens %+v",
+			len(chunked), chunked, len(wholeShot), wholeShot)
+	}
+	for i := range wholeShot {
+		if chunked[i] != wholeShot[i] {
+			t.Errorf("token %d: partial-read got %+v, single-shot got %+v", i, chunked[i], wholeShot[i])
+		}
+	}
+
+	want := []scannedToken{
+		{"foo", TokenWord},
+		{`bar="baz qux"`, TokenQuotedString},
+		{"next", TokenWord},
+		{"line", TokenWord},
+		{"here", TokenWord},
+	}
+	if len(chunked) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(chunked), chunked, len(want), want)
+	}
+	for i := range want {
+		if chunked[i] != want[i] {
+			t.Errorf("token %d: got %+v, want %+v", i, chunked[i], want[i])
+		}
+	}
+}
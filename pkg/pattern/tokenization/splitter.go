@@ -0,0 +1,214 @@
+package tokenization
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	splitterMinBufSize = 8 * 1024
+	splitterMaxBufSize = 32 * 1024
+)
+
+// errRecordTooLarge is returned by Scan when a single record doesn't fit
+// even after growing the internal buffer up to splitterMaxBufSize.
+var errRecordTooLarge = errors.New("tokenization: record exceeds splitter max buffer size")
+
+// SplitterOpts configures a StatementSplitter.
+type SplitterOpts struct {
+	// Delimiter marks the end of a record. Defaults to "\n" if empty. It can
+	// be multi-byte (e.g. "-- end") or a single control byte (";", "\x00").
+	Delimiter []byte
+	// QuoteChars are the characters that, once opened, suppress delimiter
+	// matching until the matching quote closes. Defaults to '"', '\'', '`'
+	// if nil.
+	QuoteChars []byte
+}
+
+// StatementSplitter splits a byte stream into logical records using a
+// configurable delimiter instead of the tokenizer's fixed newline/whitespace
+// rules, while honoring backslash escapes and quotes, so a delimiter inside
+// a quoted string (or right after a backslash) doesn't split the record. It's meant for multi-line
+// records a naive line-splitter upstream of the tokenizer gets wrong: stack
+// traces, SQL dumps, and multi-statement config fragments.
+type StatementSplitter struct {
+	r          io.Reader
+	delimiter  []byte
+	quoteChars [256]bool
+
+	buf      []byte
+	pos      int
+	end      int
+	recStart int
+	eof      bool
+
+	escaped      bool
+	curQuoteChar byte
+	curQuotePos  int
+
+	rec []byte
+	err error
+}
+
+// NewStatementSplitter returns a StatementSplitter reading from r.
+func NewStatementSplitter(r io.Reader, opts SplitterOpts) *StatementSplitter {
+	sp := &StatementSplitter{}
+	sp.setQuoteChars(opts.QuoteChars)
+	sp.Reset(r)
+	sp.SetDelimiter(opts.Delimiter)
+	return sp
+}
+
+func (sp *StatementSplitter) setQuoteChars(chars []byte) {
+	if len(chars) == 0 {
+		chars = []byte{'"', '\'', '`'}
+	}
+	sp.quoteChars = [256]bool{}
+	for _, c := range chars {
+		sp.quoteChars[c] = true
+	}
+}
+
+// SetDelimiter changes the record terminator, e.g. in reaction to an in-band
+// "DELIMITER //" directive in a SQL dump. It takes effect from the next call
+// to Scan on; records already returned are not re-split.
+func (sp *StatementSplitter) SetDelimiter(delim []byte) {
+	if len(delim) == 0 {
+		delim = []byte("\n")
+	}
+	sp.delimiter = delim
+}
+
+// Reset recycles the StatementSplitter to read from r, discarding any
+// buffered state. Quote chars and delimiter are left as configured.
+func (sp *StatementSplitter) Reset(r io.Reader) {
+	if sp.buf == nil {
+		sp.buf = make([]byte, splitterMinBufSize)
+	}
+	sp.r = r
+	sp.pos, sp.end, sp.recStart = 0, 0, 0
+	sp.eof = false
+	sp.escaped = false
+	sp.curQuoteChar, sp.curQuotePos = 0, -1
+	sp.rec, sp.err = nil, nil
+}
+
+// Bytes returns the most recently scanned record. The returned slice aliases
+// the splitter's internal buffer and is invalidated by the next call to Scan.
+func (sp *StatementSplitter) Bytes() []byte { return sp.rec }
+
+// Err returns the first non-EOF error encountered while reading.
+func (sp *StatementSplitter) Err() error { return sp.err }
+
+// Scan advances the splitter to the next record, returning false once the
+// underlying reader is exhausted or a read error occurs.
+func (sp *StatementSplitter) Scan() bool {
+	if sp.err != nil {
+		return false
+	}
+	for {
+		if rec, ok := sp.nextRecord(); ok {
+			sp.rec = rec
+			return true
+		}
+		if sp.eof {
+			return false
+		}
+		if err := sp.fill(); err != nil {
+			sp.err = err
+			return false
+		}
+	}
+}
+
+// fill compacts the in-progress record to the front of buf, growing it if
+// necessary, then reads more data from r.
+func (sp *StatementSplitter) fill() error {
+	if sp.recStart > 0 {
+		n := copy(sp.buf, sp.buf[sp.recStart:sp.end])
+		sp.pos -= sp.recStart
+		sp.end = n
+		sp.recStart = 0
+	}
+	if sp.end == len(sp.buf) {
+		if len(sp.buf) >= splitterMaxBufSize {
+			return errRecordTooLarge
+		}
+		grown := make([]byte, len(sp.buf)*2)
+		copy(grown, sp.buf[:sp.end])
+		sp.buf = grown
+	}
+	n, err := sp.r.Read(sp.buf[sp.end:])
+	sp.end += n
+	if err != nil {
+		if err == io.EOF {
+			sp.eof = true
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (sp *StatementSplitter) nextRecord() ([]byte, bool) {
+	for p := sp.pos; p < sp.end; p++ {
+		c := sp.buf[p]
+		switch {
+		case sp.escaped:
+			sp.escaped = false
+
+		case c == '\\':
+			sp.escaped = true
+
+		case sp.curQuotePos >= 0:
+			if c == sp.curQuoteChar {
+				sp.curQuotePos = -1
+			}
+
+		case sp.quoteChars[c]:
+			sp.curQuoteChar = c
+			sp.curQuotePos = p
+
+		case p+len(sp.delimiter) > sp.end:
+			if !sp.eof {
+				// Not enough buffered data to know whether a multi-byte
+				// delimiter matches here; stop and ask for a refill instead
+				// of risking a false negative.
+				sp.pos = p
+				return nil, false
+			}
+
+		default:
+			if sp.matchesDelimiter(p) {
+				rec := sp.buf[sp.recStart:p]
+				sp.recStart = p + len(sp.delimiter)
+				sp.pos = sp.recStart
+				return rec, true
+			}
+		}
+	}
+	sp.pos = sp.end
+
+	if !sp.eof {
+		return nil, false
+	}
+
+	if sp.recStart < sp.end {
+		rec := sp.buf[sp.recStart:sp.end]
+		sp.recStart = sp.end
+		return rec, true
+	}
+	return nil, false
+}
+
+func (sp *StatementSplitter) matchesDelimiter(p int) bool {
+	if p+len(sp.delimiter) > sp.end {
+		return false
+	}
+	for k, d := range sp.delimiter {
+		if sp.buf[p+k] != d {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package tokenization
+
+import (
+	"bytes"
+	"testing"
+)
+
+func splitAll(t *testing.T, sp *StatementSplitter) []string {
+	t.Helper()
+	var got []string
+	for sp.Scan() {
+		got = append(got, string(sp.Bytes()))
+	}
+	if err := sp.Err(); err != nil {
+		t.Fatalf("Scan error: %v", err)
+	}
+	return got
+}
+
+// TestStatementSplitterAcrossPartialReads drives multi-record input -
+// including a ';' sitting inside a double-quoted string and a
+// backslash-escaped ';' inside a single-quoted one - through a reader
+// returning 3 bytes per Read, so fill()'s
+// front-compaction runs repeatedly mid-record, and checks it matches a
+// single-shot scan of the same input.
+func TestStatementSplitterAcrossPartialReads(t *testing.T) {
+	input := "INSERT INTO t VALUES (1, \"a;b\");\nINSERT INTO t VALUES (2, 'c\\;d');\nINSERT INTO t VALUES (3);\n"
+	opts := SplitterOpts{Delimiter: []byte(";\n")}
+
+	chunked := splitAll(t, NewStatementSplitter(&chunkReader{data: []byte(input), n: 3}, opts))
+	wholeShot := splitAll(t, NewStatementSplitter(bytes.NewReader([]byte(input)), opts))
+
+	if len(chunked) != len(wholeShot) {
+		t.Fatalf("partial-read split produced %d records %q, single-shot produced %d records %q",
+			len(chunked), chunked, len(wholeShot), wholeShot)
+	}
+	for i := range wholeShot {
+		if chunked[i] != wholeShot[i] {
+			t.Errorf("record %d: partial-read got %q, single-shot got %q", i, chunked[i], wholeShot[i])
+		}
+	}
+
+	want := []string{
+		`INSERT INTO t VALUES (1, "a;b")`,
+		`INSERT INTO t VALUES (2, 'c\;d')`,
+		`INSERT INTO t VALUES (3)`,
+	}
+	if len(chunked) != len(want) {
+		t.Fatalf("got %d records %q, want %d records %q", len(chunked), chunked, len(want), want)
+	}
+	for i := range want {
+		if chunked[i] != want[i] {
+			t.Errorf("record %d: got %q, want %q", i, chunked[i], want[i])
+		}
+	}
+}
+
+// TestStatementSplitterGrowsAcrossReads forces a record larger than the
+// splitter's minimum buffer size through a reader that trickles bytes in,
+// exercising fill()'s buffer-growth path alongside its compaction path.
+func TestStatementSplitterGrowsAcrossReads(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), splitterMinBufSize+100)
+	input := append(append([]byte{}, long...), '\n')
+	sp := NewStatementSplitter(&chunkReader{data: input, n: 64}, SplitterOpts{})
+
+	if !sp.Scan() {
+		t.Fatalf("Scan() = false, err: %v", sp.Err())
+	}
+	if got := sp.Bytes(); !bytes.Equal(got, long) {
+		t.Fatalf("got record of length %d, want %d", len(got), len(long))
+	}
+	if sp.Scan() {
+		t.Fatalf("expected exactly one record, got a second: %q", sp.Bytes())
+	}
+}
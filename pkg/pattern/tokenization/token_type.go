@@ -0,0 +1,40 @@
+package tokenization
+
+// TokenType classifies a token produced by Scanner or PreprocessAndTokenizeTyped,
+// so callers don't have to re-sniff a token's shape (placeholder, quoted
+// string, JSON key...) that the tokenizer's state machine already knows.
+type TokenType int
+
+const (
+	// TokenWord is a plain, unclassified token.
+	TokenWord TokenType = iota
+	// TokenQuotedString is a token that was held together by matching quotes
+	// (UseSingleTokenForQuotes), including the quote characters themselves.
+	TokenQuotedString
+	// TokenJSONKey is the `"foo":` prefix the maybeJSON heuristic splits off
+	// of an unspaced JSON object, or the `"key":` of an object member in
+	// TokenizerOpts.StructuredJSON mode.
+	TokenJSONKey
+	// TokenJSONPunct is a synthetic `{`, `}`, `[` or `]` token emitted by
+	// TokenizerOpts.StructuredJSON mode.
+	TokenJSONPunct
+	// TokenNumberPlaceholder is a token that Preprocess collapsed from a run
+	// of digits.
+	TokenNumberPlaceholder
+	// TokenHexPlaceholder is a token that Preprocess collapsed from a run of
+	// hex digits.
+	TokenHexPlaceholder
+	// TokenDelimiter is a token made up of a single delimiter byte. It only
+	// shows up when IncludeDelimitersInTokens is set, and even then only for
+	// a delimiter with no preceding content to attach to - e.g. the second
+	// byte of a doubled delimiter ("foo  bar" -> "foo ", " ", "bar"). The
+	// ordinary case of one delimiter following real content still comes back
+	// as TokenWord, since emitDelim appends that delimiter onto the end of
+	// the token it closes rather than splitting it out.
+	TokenDelimiter
+	// TokenEOL is the synthetic placeholderEndOfLine token emitted as the
+	// MaxTokens-th token of a record that has more tokens than that, or in
+	// place of a token too large for the Scanner's buffer; the rest of the
+	// record is discarded.
+	TokenEOL
+)
@@ -0,0 +1,41 @@
+package tokenization
+
+import "bytes"
+
+// Token is a single tokenized unit together with the classification the
+// tokenizer's state machine already computed while scanning it, so callers
+// don't have to re-sniff quoted strings, JSON keys, or Preprocess
+// placeholders out of a raw []byte.
+type Token struct {
+	Value      []byte
+	Type       TokenType
+	Start, End int
+}
+
+// PreprocessAndTokenizeTyped is PreprocessAndTokenizeBytesWithOpts, except it
+// keeps the classification each token already carried internally instead of
+// discarding it.
+func PreprocessAndTokenizeTyped(content []byte, opts TokenizerOpts) []Token {
+	content = bytes.TrimSpace(content)
+	content = Preprocess(content, opts)
+
+	// See the matching comment in PreprocessAndTokenizeBytesWithOpts: Value
+	// can slice content directly instead of copying out of Scanner's
+	// internal buffer, except for TokenEOL, whose Start/End Scanner never
+	// sets.
+	s := NewScanner(bytes.NewReader(content), opts)
+	tokens := make([]Token, 0, 16)
+	for s.Scan() {
+		value := s.Bytes()
+		if s.Type() != TokenEOL {
+			value = content[s.Start():s.End()]
+		}
+		tokens = append(tokens, Token{
+			Value: value,
+			Type:  s.Type(),
+			Start: s.Start(),
+			End:   s.End(),
+		})
+	}
+	return tokens
+}
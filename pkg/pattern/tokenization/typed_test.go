@@ -0,0 +1,84 @@
+package tokenization
+
+import "testing"
+
+// TestPreprocessAndTokenizeTyped checks that each token carries the
+// classification the scan loop computed for it, not just its bytes.
+func TestPreprocessAndTokenizeTyped(t *testing.T) {
+	opts := TokenizerOpts{
+		UseSingleTokenForQuotes: true,
+		PreprocessNumbers:       true,
+		PreprocessHex:           true,
+	}
+	content := []byte(`name="log line" count=42 flags=0x1F`)
+	got := PreprocessAndTokenizeTyped(content, opts)
+	preprocessed := Preprocess(content, opts)
+
+	want := []struct {
+		value string
+		typ   TokenType
+	}{
+		{`name="log line"`, TokenQuotedString},
+		{"count=<NUM>", TokenWord},
+		{"flags=<HEX>", TokenWord},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(got), got, len(want), want)
+	}
+	for i, w := range want {
+		if string(got[i].Value) != w.value {
+			t.Errorf("token %d value: got %q, want %q", i, got[i].Value, w.value)
+		}
+		if got[i].Type != w.typ {
+			t.Errorf("token %d type: got %v, want %v", i, got[i].Type, w.typ)
+		}
+		if string(preprocessed[got[i].Start:got[i].End]) != string(got[i].Value) {
+			t.Errorf("token %d: Start/End %d:%d don't reslice to Value %q", i, got[i].Start, got[i].End, got[i].Value)
+		}
+	}
+}
+
+// TestPreprocessAndTokenizeTypedIncludeDelimiters checks when TokenDelimiter
+// actually fires with IncludeDelimitersInTokens set: a single delimiter
+// between two tokens gets appended onto the end of the token it closes and
+// comes back as TokenWord, not TokenDelimiter - that only shows up for a
+// delimiter with no preceding content to attach to, e.g. the second byte of
+// a doubled delimiter.
+func TestPreprocessAndTokenizeTypedIncludeDelimiters(t *testing.T) {
+	opts := TokenizerOpts{IncludeDelimitersInTokens: true}
+
+	got := PreprocessAndTokenizeTyped([]byte("foo bar"), opts)
+	want := []struct {
+		value string
+		typ   TokenType
+	}{
+		{"foo ", TokenWord},
+		{"bar", TokenWord},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(got), got, len(want), want)
+	}
+	for i, w := range want {
+		if string(got[i].Value) != w.value || got[i].Type != w.typ {
+			t.Errorf("token %d: got %q/%v, want %q/%v", i, got[i].Value, got[i].Type, w.value, w.typ)
+		}
+	}
+
+	gotDoubled := PreprocessAndTokenizeTyped([]byte("foo  bar"), opts)
+	wantDoubled := []struct {
+		value string
+		typ   TokenType
+	}{
+		{"foo ", TokenWord},
+		{" ", TokenDelimiter},
+		{"bar", TokenWord},
+	}
+	if len(gotDoubled) != len(wantDoubled) {
+		t.Fatalf("got %d tokens %+v, want %d tokens %+v", len(gotDoubled), gotDoubled, len(wantDoubled), wantDoubled)
+	}
+	for i, w := range wantDoubled {
+		if string(gotDoubled[i].Value) != w.value || gotDoubled[i].Type != w.typ {
+			t.Errorf("token %d: got %q/%v, want %q/%v", i, gotDoubled[i].Value, gotDoubled[i].Type, w.value, w.typ)
+		}
+	}
+}